@@ -3,14 +3,14 @@ package static
 import (
 	"embed"
 	"fmt"
+	"html/template"
 	"io"
 	"io/fs"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
-
-	platform "github.com/influxdata/influxdb/v2"
 )
 
 //go:embed build
@@ -29,91 +29,358 @@ const (
 
 // NewAssetHandler returns an http.Handler to serve files from the provided
 // path. If an empty string is provided as the path, the files are served from
-// the embedded assets.
-func NewAssetHandler(assetsPath string) http.Handler {
-	var assetHandler http.Handler
+// the embedded assets. Behavior can be further tuned with Option values.
+func NewAssetHandler(assetsPath string, opts ...Option) http.Handler {
+	var o assetHandlerOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
 
+	var fsrv *fileServer
 	if assetsPath != "" {
-		assetHandler = fileHandler2(os.DirFS(assetsPath), "")
+		fsrv = newFileServer(os.DirFS(assetsPath), "", true, assetsPath)
 	} else {
-		assetHandler = fileHandler2(assets, embedPrefix)
+		fsrv = newFileServer(assets, embedPrefix, false, "")
+	}
+
+	if o.configProvider != nil {
+		fsrv.configProvider = o.configProvider
+		if tmpl, err := parseIndexTemplate(fsrv.fs, fsrv.dir); err == nil {
+			fsrv.indexTemplate = tmpl
+		} else {
+			log.Printf("static: failed to parse %s as a template, serving it unrendered: %v", defaultFile, err)
+		}
+	}
+
+	if o.overlay.Dir != "" {
+		fsrv.overlay = os.DirFS(o.overlay.Dir)
 	}
 
-	return mwSetCacheControl(assetHandler)
+	fsrv.cacheControl = newCacheControlPolicy(o.cacheControlFingerprint)
+	return mwRequestLogger(o.logger, o.idGenerator, fsrv)
 }
 
-// mwSetCacheControl sets a default cache control header.
-func mwSetCacheControl(next http.Handler) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Cache-Control", "public, max-age=3600")
-		next.ServeHTTP(w, r)
+// fileServer serves assets from an fs.FS, transparently negotiating
+// pre-compressed variants of each asset based on the request's
+// Accept-Encoding header.
+//
+// For the embedded FS, variants are compressed into memory once at
+// construction time via compressEmbeddedAssets. For an on-disk assetsPath,
+// variants are instead looked up as sibling files (e.g. "app.js.br" next to
+// "app.js"), so that dropping a pre-compressed file next to an asset is
+// enough to serve it - no rebuild required.
+type fileServer struct {
+	fs  fs.FS
+	dir string
+
+	// onDisk is true when fs is backed by a local directory rather than the
+	// embedded build output.
+	onDisk bool
+
+	// root is the filesystem path backing fs when onDisk is true. It is only
+	// needed to hand to fsnotify, which watches paths rather than fs.FS
+	// values.
+	root string
+
+	// compressed holds in-memory pre-compressed variants for embedded
+	// assets, keyed by cleaned asset path then encoding.
+	compressed map[string]map[encoding][]byte
+
+	// etags holds a precomputed, strong content-hash ETag for every asset
+	// (and pre-compressed variant) this server can produce.
+	etags *etagCache
+
+	// configProvider and indexTemplate, when both set, cause index.html to
+	// be rendered as an html/template on every request instead of served
+	// verbatim. See WithConfigProvider.
+	configProvider ConfigProvider
+	indexTemplate  *template.Template
+
+	// overlay, when set, is checked before fs/dir for every request. See
+	// WithOverlay.
+	overlay fs.FS
+
+	// cacheControl classifies the resolved asset name into a Cache-Control
+	// header value. It is applied inside ServeHTTP, after name resolution,
+	// rather than by an outer middleware keyed on the raw request path -
+	// otherwise a SPA deep-link that resolves to index.html would be
+	// classified (and cached) as if it were some other, unfingerprinted
+	// path. See WithCacheControlFingerprint.
+	cacheControl *cacheControlPolicy
+}
+
+func newFileServer(fileOpener fs.FS, dir string, onDisk bool, root string) *fileServer {
+	fsrv := &fileServer{
+		fs:     fileOpener,
+		dir:    dir,
+		onDisk: onDisk,
+		root:   root,
+	}
+
+	if !onDisk {
+		fsrv.compressed = compressEmbeddedAssets(fileOpener, dir)
 	}
-	return http.HandlerFunc(fn)
+
+	fsrv.etags = newETagCache(fsrv)
+
+	return fsrv
 }
 
-// fileHandler takes an fs.FS and a dir name and either returns a handler that
-// either serves the file at that path, or the default file if a file cannot be
-// found at that path. An empty string can be provided for dir if the files are
-// not located in a subdirectory.
-func fileHandler2(fileOpener fs.FS, dir string) http.Handler {
-	fn := func(w http.ResponseWriter, r *http.Request) {
-		name := filepath.Clean(strings.TrimPrefix(r.URL.Path, "/"))
-
-		// If the root directory is being specifically requested, serve the index
-		// file and set the content type header, since ServeContent will set it as
-		// text/plain otherwise.
-		if name == "." {
-			name = filepath.Join(dir, defaultFile)
-			w.Header().Set("Content-Type", "text/html")
-		}
+// variantsFor returns the available pre-compressed encodings for the given
+// cleaned asset path (relative to fsrv.dir), keyed by encoding, reading each
+// variant's full content. It's only meant for the construction-time (and
+// fsnotify-triggered) etagCache refresh, which needs every variant's bytes
+// to hash - the per-request path uses the cheaper availableEncodings and
+// readVariant below instead, so a request never pays for reading variants it
+// won't serve.
+func (fsrv *fileServer) variantsFor(name string) map[encoding][]byte {
+	if !fsrv.onDisk {
+		return fsrv.compressed[name]
+	}
 
-		// Try to open the file requested by name. If it doesn't exist, try to
-		// open the index file.
-		f, err := fileOpener.Open(filepath.Join(dir, name))
+	variants := make(map[encoding][]byte)
+	for enc, suffix := range diskSuffixes {
+		data, err := fs.ReadFile(fsrv.fs, filepath.Join(fsrv.dir, name+suffix))
 		if err != nil {
-			if os.IsNotExist(err) {
-				f, err = fileOpener.Open(filepath.Join(dir, defaultFile))
-			}
-			if err != nil {
-				// If the index can't be found, the binary must not have been built with
-				// assets, so return no content.
-				http.Error(w, err.Error(), http.StatusNoContent)
-				return
+			continue
+		}
+		variants[enc] = data
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	return variants
+}
+
+// availableEncodings reports which pre-compressed encodings exist for name
+// without reading their content, so per-request negotiation doesn't pay to
+// load variants that won't be served. For on-disk assets this is a cheap
+// stat of each sibling file; for embedded assets it's a lookup into the
+// in-memory cache built at construction time.
+func (fsrv *fileServer) availableEncodings(name string) map[encoding]bool {
+	if !fsrv.onDisk {
+		variants := fsrv.compressed[name]
+		if len(variants) == 0 {
+			return nil
+		}
+		out := make(map[encoding]bool, len(variants))
+		for enc := range variants {
+			out[enc] = true
+		}
+		return out
+	}
+
+	var out map[encoding]bool
+	for enc, suffix := range diskSuffixes {
+		if _, err := fs.Stat(fsrv.fs, filepath.Join(fsrv.dir, name+suffix)); err == nil {
+			if out == nil {
+				out = make(map[encoding]bool, len(diskSuffixes))
 			}
-			// Like above, the content type needs to be set for the index file.
-			// If we got here, the index must have been found.
-			w.Header().Set("Content-Type", "text/html")
+			out[enc] = true
 		}
+	}
+	return out
+}
+
+// readVariant returns the pre-compressed bytes for name in encoding enc,
+// reading the single negotiated sibling file on demand for on-disk assets,
+// or returning the already in-memory bytes for embedded ones.
+func (fsrv *fileServer) readVariant(name string, enc encoding) ([]byte, error) {
+	if !fsrv.onDisk {
+		return fsrv.compressed[name][enc], nil
+	}
+	suffix, ok := diskSuffixes[enc]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return fs.ReadFile(fsrv.fs, filepath.Join(fsrv.dir, name+suffix))
+}
+
+func (fsrv *fileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := filepath.Clean(strings.TrimPrefix(r.URL.Path, "/"))
+	isDefault := false
+	if name == "." {
+		name = defaultFile
+		isDefault = true
+	}
+
+	// The overlay is checked against the originally-requested name first,
+	// before any base-tree resolution below. A file that exists only in the
+	// overlay - not anywhere in the embedded/on-disk asset tree - would
+	// otherwise never be recognized as "known" and would be rewritten to
+	// defaultFile by the SPA fallback before the overlay was ever consulted,
+	// so adding a brand-new overlay file would silently serve index.html
+	// instead.
+	if f, ok, err := fsrv.openOverlay(name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if ok {
 		defer f.Close()
+		fsrv.serveOverlayFile(w, r, name, f, isDefault)
+		return
+	}
 
-		i, err := f.Stat()
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusForbidden)
+	// Negotiate the encoding and look up its precomputed, strong ETag before
+	// opening anything. If name isn't a recognized asset, fall back to the
+	// default file the same way the open-based path below does.
+	available := fsrv.availableEncodings(name)
+	enc := negotiateEncoding(r.Header.Get("Accept-Encoding"), available)
+	tag, known := fsrv.etags.get(name, enc)
+	if !known && !isDefault {
+		name = defaultFile
+		isDefault = true
+
+		// The base tree doesn't have this path, so the request resolves to
+		// index.html: check the overlay again with the resolved name, so an
+		// overlay index.html is still picked up for SPA routes that fall
+		// back to it, not just literal "/" requests.
+		if f, ok, err := fsrv.openOverlay(name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if ok {
+			defer f.Close()
+			fsrv.serveOverlayFile(w, r, name, f, isDefault)
 			return
 		}
 
-		content, ok := f.(io.ReadSeeker)
-		if !ok {
-			// Shouldn't ever get an error here, so return an internal error.
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		available = fsrv.availableEncodings(name)
+		enc = negotiateEncoding(r.Header.Get("Accept-Encoding"), available)
+		tag, known = fsrv.etags.get(name, enc)
+	}
+
+	if name == defaultFile && fsrv.indexTemplate != nil {
+		fsrv.serveTemplatedIndex(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fsrv.cacheControl.valueFor(name))
+
+	if known {
+		w.Header().Set("Vary", "Accept-Encoding")
+		if enc != encodingIdentity {
+			w.Header().Set("Content-Encoding", string(enc))
+		}
+		w.Header().Set("ETag", tag)
+		if isDefault {
+			w.Header().Set("Content-Type", "text/html")
+		}
+
+		// A matching If-None-Match can be answered straight from the cache -
+		// no need to open or stat the underlying file at all.
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Try to open the file requested by name. If it doesn't exist, try to
+	// open the index file.
+	f, err := fsrv.fs.Open(filepath.Join(fsrv.dir, name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			name = defaultFile
+			f, err = fsrv.fs.Open(filepath.Join(fsrv.dir, name))
+		}
+		if err != nil {
+			// If the index can't be found, the binary must not have been built with
+			// assets, so return no content.
+			http.Error(w, err.Error(), http.StatusNoContent)
 			return
 		}
+		// Like above, the content type needs to be set for the index file.
+		// If we got here, the index must have been found.
+		w.Header().Set("Content-Type", "text/html")
+	}
+	defer f.Close()
+
+	i, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
-		// The etag will be set using some readily available information. The asset
-		// files should only change if the user is running a new release, or have
-		// set a different asset path. The chance of collisions is low, so a weak
-		// tag can be used without much risk.
-		etag := fmt.Sprintf(`W/"%s-%d-%s"`, i.Name(), i.Size(), platform.GetBuildInfo().Commit)
-		w.Header().Set("ETag", etag)
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		// Shouldn't ever get an error here, so return an internal error.
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		// ServeContent will automatically set the content-type header for files
-		// other than index.html, and will also set the Last-Modified header.
-		// ModTime will be time.Time{} for embedded assets, so a Last-Modified
-		// header can't be set.
-		http.ServeContent(w, r, name, i.ModTime(), content)
+	// Serve the negotiated pre-compressed variant's bytes if one was picked
+	// above, otherwise the file's own content. The variant is read here, on
+	// demand, rather than up front alongside its siblings, since at most one
+	// of them is ever used.
+	var body io.ReadSeeker = content
+	if enc != encodingIdentity {
+		if data, err := fsrv.readVariant(name, enc); err == nil && data != nil {
+			body = newSliceReadSeeker(data)
+		}
 	}
 
-	return http.HandlerFunc(fn)
+	// ServeContent will automatically set the content-type header for files
+	// other than index.html, and will also set the Last-Modified header.
+	// ModTime will be time.Time{} for embedded assets, so a Last-Modified
+	// header can't be set. ServeContent also handles range requests against
+	// the (possibly compressed) body above.
+	http.ServeContent(w, r, name, i.ModTime(), body)
+}
+
+// etagMatches reports whether tag appears in the comma-separated
+// If-None-Match header value ifNoneMatch, per RFC 7232 §2.3.2. The weak
+// "W/" prefix, if any, is ignored on both sides.
+func etagMatches(ifNoneMatch, tag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	if ifNoneMatch == "*" {
+		return true
+	}
+	want := strings.TrimPrefix(tag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// sliceReadSeeker adapts an in-memory byte slice to io.ReadSeeker so a
+// pre-compressed variant can be served through http.ServeContent.
+type sliceReadSeeker struct {
+	b   []byte
+	pos int64
+}
+
+func newSliceReadSeeker(b []byte) *sliceReadSeeker {
+	return &sliceReadSeeker{b: b}
+}
+
+func (s *sliceReadSeeker) Read(p []byte) (int, error) {
+	if s.pos >= int64(len(s.b)) {
+		return 0, io.EOF
+	}
+	n := copy(p, s.b[s.pos:])
+	s.pos += int64(n)
+	return n, nil
+}
+
+func (s *sliceReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = s.pos + offset
+	case io.SeekEnd:
+		abs = int64(len(s.b)) + offset
+	default:
+		return 0, fmt.Errorf("sliceReadSeeker.Seek: invalid whence %d", whence)
+	}
+	if abs < 0 {
+		return 0, fmt.Errorf("sliceReadSeeker.Seek: negative position")
+	}
+	s.pos = abs
+	return abs, nil
 }
 
 // A much simpler implementation which does not set etags is below....
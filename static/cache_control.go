@@ -0,0 +1,56 @@
+package static
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// cacheControlNoCache is used for index.html and other unfingerprinted
+	// HTML so that a new deploy takes effect immediately instead of being
+	// served stale out of a cache.
+	cacheControlNoCache = "no-cache, must-revalidate"
+
+	// cacheControlImmutable is used for bundler output whose filename
+	// already encodes a content hash - the content at a given URL can never
+	// change, so it can be cached forever.
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+
+	// cacheControlDefault is used for everything else: static files that
+	// change infrequently but aren't fingerprinted.
+	cacheControlDefault = "public, max-age=3600"
+)
+
+// defaultFingerprintPattern matches filenames carrying a bundler-emitted
+// content hash, e.g. "main.3f9a21c8e1.js" or "vendor-8b1c9d2e.css".
+var defaultFingerprintPattern = regexp.MustCompile(`[.-][0-9a-f]{8,}\.(?:js|css|woff2?|ttf|eot|svg|png|jpe?g|ico|map)$`)
+
+// cacheControlPolicy classifies a resolved asset path into the Cache-Control value
+// that should be sent with its response.
+type cacheControlPolicy struct {
+	fingerprint *regexp.Regexp
+}
+
+func newCacheControlPolicy(fingerprint *regexp.Regexp) *cacheControlPolicy {
+	if fingerprint == nil {
+		fingerprint = defaultFingerprintPattern
+	}
+	return &cacheControlPolicy{fingerprint: fingerprint}
+}
+
+// valueFor returns the Cache-Control header value for name, the asset path
+// the request ultimately resolved to - e.g. defaultFile for both "/" and any
+// SPA route that fell back to it, not just a literal request for
+// defaultFile. Callers must classify against this resolved name rather than
+// the raw request path, since the two can differ.
+func (p *cacheControlPolicy) valueFor(name string) string {
+	switch {
+	case name == defaultFile || strings.EqualFold(filepath.Ext(name), ".html"):
+		return cacheControlNoCache
+	case p.fingerprint.MatchString(name):
+		return cacheControlImmutable
+	default:
+		return cacheControlDefault
+	}
+}
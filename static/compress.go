@@ -0,0 +1,212 @@
+package static
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/fs"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encoding identifies a content-coding that can be applied to a static asset.
+type encoding string
+
+const (
+	encodingIdentity encoding = ""
+	encodingGzip     encoding = "gzip"
+	encodingBrotli   encoding = "br"
+	encodingZstd     encoding = "zstd"
+)
+
+// diskSuffixes maps an encoding to the file extension used for its
+// pre-compressed companion file on disk, e.g. "app.js" -> "app.js.br".
+var diskSuffixes = map[encoding]string{
+	encodingGzip:   ".gz",
+	encodingBrotli: ".br",
+	encodingZstd:   ".zst",
+}
+
+// compressibleMimeTypes lists the content types worth compressing. Formats
+// that are already compressed (images, fonts, media) are left alone since
+// recompressing them wastes CPU for little or no size benefit.
+var compressibleMimeTypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/plain":             true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"application/json":       true,
+	"image/svg+xml":          true,
+	"application/wasm":       true,
+}
+
+// isCompressible reports whether name's extension maps to a mime type worth
+// pre-compressing.
+func isCompressible(name string) bool {
+	// mime.TypeByExtension often returns a charset-suffixed type, e.g.
+	// "text/javascript; charset=utf-8", so the media type has to be
+	// extracted before comparing against compressibleMimeTypes' bare types.
+	mediaType, _, err := mime.ParseMediaType(mime.TypeByExtension(filepath.Ext(name)))
+	if err != nil {
+		return false
+	}
+	return compressibleMimeTypes[mediaType]
+}
+
+// negotiateEncoding parses an Accept-Encoding header value per RFC 7231
+// §5.3.4, including q-values, and returns the highest-priority encoding that
+// is both requested and present in available. It returns encodingIdentity if
+// the header is empty, unparseable, or no requested encoding is available.
+// available only needs to report which encodings exist, not their bytes, so
+// callers that haven't read the variants' content yet can still negotiate.
+func negotiateEncoding(acceptEncoding string, available map[encoding]bool) encoding {
+	if acceptEncoding == "" || len(available) == 0 {
+		return encodingIdentity
+	}
+
+	type candidate struct {
+		enc encoding
+		q   float64
+	}
+	var candidates []candidate
+
+	// named records every encoding listed explicitly, including those
+	// refused with q=0, so that `*` only applies to the rest.
+	named := make(map[encoding]bool)
+	starQ := 0.0
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name := part
+		q := 1.0
+		if idx := strings.IndexByte(part, ';'); idx >= 0 {
+			name = strings.TrimSpace(part[:idx])
+			if v, ok := parseQValue(part[idx+1:]); ok {
+				q = v
+			}
+		}
+		enc := encoding(strings.ToLower(name))
+		if enc == "*" {
+			starQ = q
+			continue
+		}
+		named[enc] = true
+		if q <= 0 {
+			continue
+		}
+		if _, ok := available[enc]; ok {
+			candidates = append(candidates, candidate{enc, q})
+		}
+	}
+
+	// `*` matches any encoding not explicitly named elsewhere in the
+	// header; expand it so explicit entries can still outrank it.
+	if starQ > 0 {
+		for e := range available {
+			if !named[e] {
+				candidates = append(candidates, candidate{e, starQ})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return encodingIdentity
+	}
+
+	// Break q-value ties by server preference rather than the client's
+	// header order: zstd and brotli compress smaller than gzip for the same
+	// content, so prefer them whenever the client accepts either at the
+	// same priority.
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].q != candidates[j].q {
+			return candidates[i].q > candidates[j].q
+		}
+		return encodingPreference[candidates[i].enc] > encodingPreference[candidates[j].enc]
+	})
+
+	return candidates[0].enc
+}
+
+// encodingPreference ranks encodings for tie-breaking equal-quality
+// Accept-Encoding candidates, highest first.
+var encodingPreference = map[encoding]int{
+	encodingZstd:   3,
+	encodingBrotli: 2,
+	encodingGzip:   1,
+}
+
+// parseQValue parses the "q=0.8" parameter of an Accept-Encoding entry.
+func parseQValue(param string) (float64, bool) {
+	param = strings.TrimSpace(param)
+	if !strings.HasPrefix(param, "q=") {
+		return 0, false
+	}
+	q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+	if err != nil {
+		return 0, false
+	}
+	return q, true
+}
+
+// compressEmbeddedAssets walks fsys rooted at dir and returns, for every
+// compressible file, its gzip/brotli/zstd encoded bytes keyed by cleaned
+// asset path and encoding. It is intended to run once at handler
+// construction time so requests never pay the compression cost.
+func compressEmbeddedAssets(fsys fs.FS, dir string) map[string]map[encoding][]byte {
+	out := make(map[string]map[encoding][]byte)
+
+	root := "."
+	if dir != "" {
+		root = dir
+	}
+
+	_ = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isCompressible(path) {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil
+		}
+
+		variants := make(map[encoding][]byte)
+
+		var gz bytes.Buffer
+		gw := gzip.NewWriter(&gz)
+		if _, err := gw.Write(data); err == nil && gw.Close() == nil {
+			variants[encodingGzip] = gz.Bytes()
+		}
+
+		var br bytes.Buffer
+		bw := brotli.NewWriter(&br)
+		if _, err := bw.Write(data); err == nil && bw.Close() == nil {
+			variants[encodingBrotli] = br.Bytes()
+		}
+
+		var zs bytes.Buffer
+		if zw, err := zstd.NewWriter(&zs); err == nil {
+			if _, err := zw.Write(data); err == nil && zw.Close() == nil {
+				variants[encodingZstd] = zs.Bytes()
+			}
+		}
+
+		if len(variants) > 0 {
+			rel := relName(path, dir)
+			out[rel] = variants
+		}
+		return nil
+	})
+
+	return out
+}
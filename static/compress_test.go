@@ -0,0 +1,32 @@
+package static
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCompressEmbeddedAssets_CompressesJSAndCSS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"build/app.js":  {Data: []byte("console.log('hello world, this text needs to be long enough to compress');")},
+		"build/app.css": {Data: []byte("body { color: red; padding: 0; margin: 0; /* filler for compression */ }")},
+		"build/app.png": {Data: []byte{0x89, 0x50, 0x4e, 0x47}},
+	}
+
+	got := compressEmbeddedAssets(fsys, "build")
+
+	for _, name := range []string{"app.js", "app.css"} {
+		variants, ok := got[name]
+		if !ok {
+			t.Fatalf("expected compressed variants for %s, got none", name)
+		}
+		for _, enc := range []encoding{encodingGzip, encodingBrotli, encodingZstd} {
+			if _, ok := variants[enc]; !ok {
+				t.Errorf("%s: missing %s variant", name, enc)
+			}
+		}
+	}
+
+	if _, ok := got["app.png"]; ok {
+		t.Errorf("app.png is not a compressible mime type and should not have been compressed")
+	}
+}
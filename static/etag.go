@@ -0,0 +1,157 @@
+package static
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// etagKey identifies one servable variant of one asset: its cleaned path
+// relative to the handler's root, plus the content-coding of the bytes that
+// would actually be written to the response body.
+type etagKey struct {
+	name string
+	enc  encoding
+}
+
+// etagCache holds a strong, content-hash ETag for every asset - and every
+// pre-compressed variant of every asset - a fileServer can produce. Tags are
+// computed eagerly so that a request can be answered with a 304 purely from
+// the cache, without opening or re-hashing the underlying file.
+type etagCache struct {
+	mu   sync.RWMutex
+	tags map[etagKey]string
+
+	fsrv    *fileServer
+	watcher *fsnotify.Watcher
+}
+
+// newETagCache walks fsrv's filesystem, hashing every asset it finds, and
+// for an on-disk asset tree starts a best-effort fsnotify watcher that
+// refreshes a single entry when its file changes rather than forcing a full
+// rebuild.
+func newETagCache(fsrv *fileServer) *etagCache {
+	c := &etagCache{
+		tags: make(map[etagKey]string),
+		fsrv: fsrv,
+	}
+
+	root := "."
+	if fsrv.dir != "" {
+		root = fsrv.dir
+	}
+	_ = fs.WalkDir(fsrv.fs, root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		c.refresh(relName(path, fsrv.dir))
+		return nil
+	})
+
+	if fsrv.onDisk {
+		c.watch()
+	}
+
+	return c
+}
+
+// refresh (re)computes and stores the ETag for name's identity body and
+// every pre-compressed variant currently available for it.
+func (c *etagCache) refresh(name string) {
+	tags := map[etagKey]string{}
+
+	if data, err := fs.ReadFile(c.fsrv.fs, filepath.Join(c.fsrv.dir, name)); err == nil {
+		tags[etagKey{name, encodingIdentity}] = hashETag(data)
+	}
+	for enc, data := range c.fsrv.variantsFor(name) {
+		tags[etagKey{name, enc}] = hashETag(data)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, v := range tags {
+		c.tags[k] = v
+	}
+}
+
+// get returns the cached strong ETag for name served with enc, if any.
+func (c *etagCache) get(name string, enc encoding) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	tag, ok := c.tags[etagKey{name, enc}]
+	return tag, ok
+}
+
+// hashETag returns a quoted strong ETag computed from the SHA-256 of data.
+func hashETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:]))
+}
+
+// relName strips dir from path the same way fsrv.variantsFor expects names
+// to be expressed, i.e. relative to the handler's root.
+func relName(path, dir string) string {
+	rel := strings.TrimPrefix(path, dir)
+	return strings.TrimPrefix(rel, "/")
+}
+
+// watch starts a best-effort fsnotify watcher over the on-disk asset
+// directory. Failures to create or configure the watcher are logged and
+// otherwise ignored: the handler keeps serving whatever was hashed at
+// construction time, it just won't pick up further edits without a
+// restart.
+func (c *etagCache) watch() {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("static: fsnotify unavailable, asset ETag cache will not auto-refresh: %v", err)
+		return
+	}
+	// fsnotify watches are not recursive, so every directory of the asset
+	// tree is added on its own. Directories created after this point are not
+	// watched.
+	err = fs.WalkDir(c.fsrv.fs, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		return w.Add(filepath.Join(c.fsrv.root, path))
+	})
+	if err != nil {
+		log.Printf("static: failed to watch asset directory %q: %v", c.fsrv.root, err)
+		w.Close()
+		return
+	}
+	c.watcher = w
+
+	go func() {
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					name := relName(event.Name, c.fsrv.root)
+					c.refresh(name)
+					// A changed pre-compressed sibling also changes the
+					// variants of the asset it belongs to.
+					for _, suffix := range diskSuffixes {
+						if base := strings.TrimSuffix(name, suffix); base != name {
+							c.refresh(base)
+						}
+					}
+				}
+			case _, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,125 @@
+package static
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// requestIDContextKey is the context key under which mwRequestLogger stores
+// the request ID it read or generated.
+type requestIDContextKey struct{}
+
+// RequestIDFromContext returns the request ID the asset handler's logging
+// middleware associated with ctx's request, if any.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey{}).(string)
+	return id, ok
+}
+
+// IDGenerator produces a new request ID. WithIDGenerator lets callers pin
+// this in tests; the default returns 16 random bytes as a 32-character hex
+// string.
+type IDGenerator func() string
+
+func defaultIDGenerator() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// WithLogger injects a *zap.Logger that the asset handler uses to emit one
+// structured access-log line per request. If not provided, no access log
+// is written, though request IDs are still generated and echoed.
+func WithLogger(logger *zap.Logger) Option {
+	return func(o *assetHandlerOptions) {
+		o.logger = logger
+	}
+}
+
+// WithIDGenerator overrides how request IDs are generated, so tests can pin
+// a deterministic value instead of a random one.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(o *assetHandlerOptions) {
+		o.idGenerator = gen
+	}
+}
+
+// mwRequestLogger reads an inbound X-Request-Id or generates one with gen,
+// stores it on the request context and echoes it in the response header,
+// then logs one structured line per request through logger once the
+// wrapped handler returns. logger may be nil, in which case only request-id
+// propagation happens.
+func mwRequestLogger(logger *zap.Logger, gen IDGenerator, next http.Handler) http.Handler {
+	if gen == nil {
+		gen = defaultIDGenerator
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-Id")
+		if !validRequestID(id) {
+			id = gen()
+		}
+		w.Header().Set("X-Request-Id", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDContextKey{}, id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		defer func() {
+			if logger == nil {
+				return
+			}
+			logger.Info("asset request",
+				zap.String("request_id", id),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rec.status),
+				zap.Int("bytes", rec.bytes),
+				zap.Duration("duration", time.Since(start)),
+				zap.String("content_encoding", rec.Header().Get("Content-Encoding")),
+				zap.Bool("cache_hit", rec.status == http.StatusNotModified),
+			)
+		}()
+
+		next.ServeHTTP(rec, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// validRequestID reports whether an inbound X-Request-Id is reasonable to
+// echo back and log as-is: non-empty, short enough to not bloat logs, and
+// free of characters that don't belong in an HTTP header value.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder wraps an http.ResponseWriter to capture the status code and
+// byte count written, for access logging.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(p []byte) (int, error) {
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += n
+	return n, err
+}
@@ -0,0 +1,31 @@
+package static
+
+import (
+	"regexp"
+
+	"go.uber.org/zap"
+)
+
+// Option configures optional behavior of the handler returned by
+// NewAssetHandler.
+type Option func(*assetHandlerOptions)
+
+// assetHandlerOptions collects the pieces of NewAssetHandler's behavior
+// that can be tuned via Option without rebuilding the UI bundle.
+type assetHandlerOptions struct {
+	cacheControlFingerprint *regexp.Regexp
+	configProvider          ConfigProvider
+	overlay                 OverlayConfig
+	logger                  *zap.Logger
+	idGenerator             IDGenerator
+}
+
+// WithCacheControlFingerprint overrides the regex used to recognize
+// bundler-fingerprinted asset filenames - e.g. "main.3f9a21c8e1.js" - that
+// should be served with a long, immutable Cache-Control policy rather than
+// the moderate default. If not provided, defaultFingerprintPattern is used.
+func WithCacheControlFingerprint(pattern *regexp.Regexp) Option {
+	return func(o *assetHandlerOptions) {
+		o.cacheControlFingerprint = pattern
+	}
+}
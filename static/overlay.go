@@ -0,0 +1,82 @@
+package static
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// OverlayConfig configures a local directory that is checked before the
+// handler's normal asset tree (embedded build/ output, or an on-disk
+// assetsPath), so an operator can customize a logo, favicon, or a single
+// HTML fragment without forking and rebuilding influxd.
+type OverlayConfig struct {
+	// Dir is the local directory checked first for every request, e.g.
+	// "/etc/influxdb/ui-overrides". A zero value disables the overlay.
+	Dir string
+}
+
+// WithOverlay layers cfg.Dir on top of the handler's normal asset tree: a
+// request is served from cfg.Dir first, and falls back to the usual asset
+// resolution (including the SPA's index.html fallback) on fs.ErrNotExist.
+//
+// Overlay files are served as-is - the pre-compressed-variant negotiation
+// pipeline (see compressEmbeddedAssets and fileServer.availableEncodings)
+// remains exclusive to the embedded/on-disk asset trees - but they still go
+// through the same ETag and Cache-Control policy as everything else.
+func WithOverlay(cfg OverlayConfig) Option {
+	return func(o *assetHandlerOptions) {
+		o.overlay = cfg
+	}
+}
+
+// openOverlay opens name from the overlay filesystem, if one is configured.
+// It reports ok=false with a nil error both when no overlay is configured
+// and when the overlay doesn't have name, so callers can fall back to the
+// base asset tree in either case without treating "not found" as failure.
+func (fsrv *fileServer) openOverlay(name string) (f fs.File, ok bool, err error) {
+	if fsrv.overlay == nil {
+		return nil, false, nil
+	}
+	f, err = fsrv.overlay.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+// serveOverlayFile serves f, an already-opened file from the overlay
+// directory, computing its ETag from its content since overlay files aren't
+// part of the precomputed etagCache.
+func (fsrv *fileServer) serveOverlayFile(w http.ResponseWriter, r *http.Request, name string, f fs.File, isDefault bool) {
+	i, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", fsrv.cacheControl.valueFor(name))
+	if isDefault {
+		w.Header().Set("Content-Type", "text/html")
+	}
+
+	tag := hashETag(data)
+	w.Header().Set("ETag", tag)
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	http.ServeContent(w, r, name, i.ModTime(), bytes.NewReader(data))
+}
@@ -0,0 +1,83 @@
+package static
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// ConfigProvider returns the data to inject into index.html's
+// #influx-config script tag for a given request - build commit, feature
+// flags, cluster id, an OIDC discovery URL, a CSRF token minted by
+// surrounding middleware, and so on.
+type ConfigProvider func(*http.Request) map[string]any
+
+// WithConfigProvider causes index.html to be rendered as an html/template on
+// every request, with fn's return value JSON-encoded and exposed to the
+// template as a single `{{.}}` value, so server-side values can reach the
+// UI bundle through something like
+// `<script id="influx-config">{{.}}</script>` without rebuilding it.
+// Non-HTML assets bypass templating entirely.
+func WithConfigProvider(fn ConfigProvider) Option {
+	return func(o *assetHandlerOptions) {
+		o.configProvider = fn
+	}
+}
+
+// parseIndexTemplate parses the index.html found at dir/defaultFile in fsys
+// as an html/template. It is called once at handler construction time so
+// that serving a request only has to execute the already-parsed template.
+func parseIndexTemplate(fsys fs.FS, dir string) (*template.Template, error) {
+	data, err := fs.ReadFile(fsys, filepath.Join(dir, defaultFile))
+	if err != nil {
+		return nil, err
+	}
+	return template.New(defaultFile).Parse(string(data))
+}
+
+// serveTemplatedIndex renders fsrv.indexTemplate with this request's config
+// data and serves the result. The ETag is computed over the rendered
+// bytes rather than the template source, since the two can differ on every
+// request.
+func (fsrv *fileServer) serveTemplatedIndex(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{}
+	if fsrv.configProvider != nil {
+		data = fsrv.configProvider(r)
+	}
+
+	// json.Marshal HTML-escapes '<', '>' and '&' by default, so the result
+	// is safe to place verbatim inside a <script> tag even when a config
+	// value (a CSRF token, an OIDC URL, ...) contains "</script>" or other
+	// markup. html/template's contextual autoescaping is still in effect
+	// for the rest of the document; template.JS only opts this one,
+	// already-escaped value out of further JS-string escaping so the JSON
+	// stays valid.
+	configJSON, err := json.Marshal(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := fsrv.indexTemplate.Execute(&buf, template.JS(configJSON)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rendered := buf.Bytes()
+	tag := hashETag(rendered)
+
+	w.Header().Set("Cache-Control", fsrv.cacheControl.valueFor(defaultFile))
+	w.Header().Set("Content-Type", "text/html")
+	w.Header().Set("ETag", tag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	http.ServeContent(w, r, defaultFile, time.Time{}, bytes.NewReader(rendered))
+}